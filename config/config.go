@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+const configFilePath = "config.json"
+
+// GuildConfig holds the per-guild overrides for the bot's behaviour.
+type GuildConfig struct {
+	Prefix            string   `json:"prefix,omitempty"`
+	MaxQueueSize      int      `json:"maxQueueSize,omitempty"`
+	DJRoleId          string   `json:"djRoleId,omitempty"`
+	AllowedChannelIds []string `json:"allowedChannelIds,omitempty"`
+	VoteSkipThreshold float64  `json:"voteSkipThreshold,omitempty"`
+}
+
+// defaultVoteSkipThreshold is the fraction of non-bot members in the voice
+// channel that must vote to skip before a !voteskip passes.
+const defaultVoteSkipThreshold = 0.5
+
+// Config is the root of config.json.
+type Config struct {
+	DiscordToken  string                  `json:"discordToken"`
+	YoutubeApiKey string                  `json:"youtubeApiKey"`
+	CommandPrefix string                  `json:"commandPrefix"`
+	MaxQueueSize  int                     `json:"maxQueueSize"`
+	CacheDir      string                  `json:"cacheDir"`
+	CacheMaxBytes int64                   `json:"cacheMaxBytes"`
+	Guilds        map[string]*GuildConfig `json:"guilds"`
+}
+
+var (
+	config      *Config
+	configMutex = sync.Mutex{}
+)
+
+// Load reads config.json from disk, falling back to the DISCORD_TOKEN and
+// YOUTUBE_API_KEY environment variables for credentials if the file doesn't
+// exist yet.
+func Load() {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	config = &Config{
+		CommandPrefix: "!",
+		MaxQueueSize:  10,
+		CacheDir:      "cache",
+		CacheMaxBytes: 2 * 1024 * 1024 * 1024, // 2 GiB
+		Guilds:        make(map[string]*GuildConfig),
+	}
+	file, err := os.ReadFile(configFilePath)
+	if err != nil {
+		log.Printf("No %s found, falling back to environment variables: %s", configFilePath, err.Error())
+		config.DiscordToken = os.Getenv("DISCORD_TOKEN")
+		config.YoutubeApiKey = os.Getenv("YOUTUBE_API_KEY")
+		return
+	}
+	if err := json.Unmarshal(file, config); err != nil {
+		log.Fatalf("Failed to parse %s: %s", configFilePath, err.Error())
+	}
+	if config.Guilds == nil {
+		config.Guilds = make(map[string]*GuildConfig)
+	}
+}
+
+// Get returns the loaded config. Load must be called first.
+func Get() *Config {
+	return config
+}
+
+// Save atomically persists the current config back to config.json by
+// writing to a temporary file and renaming it over the original, so a crash
+// mid-write can never leave config.json truncated.
+func Save() error {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config: %s", err.Error())
+	}
+	tmpFilePath := configFilePath + ".tmp"
+	if err := os.WriteFile(tmpFilePath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %s", tmpFilePath, err.Error())
+	}
+	if err := os.Rename(tmpFilePath, configFilePath); err != nil {
+		return fmt.Errorf("failed to rename %s to %s: %s", tmpFilePath, configFilePath, err.Error())
+	}
+	return nil
+}
+
+// GuildConfig returns the config for guildID, creating a default one if it
+// doesn't exist yet.
+func (c *Config) GuildConfig(guildID string) *GuildConfig {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	guildConfig, ok := c.Guilds[guildID]
+	if !ok {
+		guildConfig = &GuildConfig{}
+		c.Guilds[guildID] = guildConfig
+	}
+	return guildConfig
+}
+
+// GetPrefix returns the guild's command prefix, falling back to the global
+// default if it hasn't overridden it.
+func (g *GuildConfig) GetPrefix() string {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if g.Prefix != "" {
+		return g.Prefix
+	}
+	return config.CommandPrefix
+}
+
+// SetPrefix overrides the guild's command prefix.
+func (g *GuildConfig) SetPrefix(prefix string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	g.Prefix = prefix
+}
+
+// GetMaxQueueSize returns the guild's max queue size, falling back to the
+// global default if it hasn't overridden it.
+func (g *GuildConfig) GetMaxQueueSize() int {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if g.MaxQueueSize > 0 {
+		return g.MaxQueueSize
+	}
+	return config.MaxQueueSize
+}
+
+// GetDJRoleId returns the role ID required to manage playback in the guild,
+// or an empty string if no DJ role has been set.
+func (g *GuildConfig) GetDJRoleId() string {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	return g.DJRoleId
+}
+
+// SetDJRoleId sets the role ID required to manage playback in the guild.
+func (g *GuildConfig) SetDJRoleId(roleId string) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	g.DJRoleId = roleId
+}
+
+// GetVoteSkipThreshold returns the fraction of non-bot members that must
+// vote to skip, falling back to the default if the guild hasn't overridden
+// it.
+func (g *GuildConfig) GetVoteSkipThreshold() float64 {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if g.VoteSkipThreshold > 0 {
+		return g.VoteSkipThreshold
+	}
+	return defaultVoteSkipThreshold
+}
+
+// IsChannelAllowed reports whether the bot should respond in channelID. An
+// empty allow-list means every channel is allowed.
+func (g *GuildConfig) IsChannelAllowed(channelID string) bool {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	if len(g.AllowedChannelIds) == 0 {
+		return true
+	}
+	for _, id := range g.AllowedChannelIds {
+		if id == channelID {
+			return true
+		}
+	}
+	return false
+}
+
+// AddAllowedChannel binds the guild's commands to channelID, reporting false
+// if it was already bound instead of adding a duplicate.
+func (g *GuildConfig) AddAllowedChannel(channelID string) bool {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+	for _, id := range g.AllowedChannelIds {
+		if id == channelID {
+			return false
+		}
+	}
+	g.AllowedChannelIds = append(g.AllowedChannelIds, channelID)
+	return true
+}
@@ -0,0 +1,98 @@
+package config
+
+import "testing"
+
+// withTestConfig points the package-level config at a fresh Config with the
+// given global defaults, so tests don't depend on Load's file/env fallback.
+func withTestConfig(commandPrefix string, maxQueueSize int) {
+	config = &Config{
+		CommandPrefix: commandPrefix,
+		MaxQueueSize:  maxQueueSize,
+		Guilds:        make(map[string]*GuildConfig),
+	}
+}
+
+func TestGuildConfig_GetPrefixFallsBackToGlobalDefault(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	if prefix := guildConfig.GetPrefix(); prefix != "!" {
+		t.Errorf("expected the global default \"!\", got %q", prefix)
+	}
+}
+
+func TestGuildConfig_GetPrefixPrefersOverride(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	guildConfig.SetPrefix("?")
+	if prefix := guildConfig.GetPrefix(); prefix != "?" {
+		t.Errorf("expected the override \"?\", got %q", prefix)
+	}
+}
+
+func TestGuildConfig_GetMaxQueueSizeFallsBackToGlobalDefault(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	if size := guildConfig.GetMaxQueueSize(); size != 10 {
+		t.Errorf("expected the global default 10, got %d", size)
+	}
+	guildConfig.MaxQueueSize = 25
+	if size := guildConfig.GetMaxQueueSize(); size != 25 {
+		t.Errorf("expected the override 25, got %d", size)
+	}
+}
+
+func TestGuildConfig_GetVoteSkipThresholdFallsBackToDefault(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	if threshold := guildConfig.GetVoteSkipThreshold(); threshold != defaultVoteSkipThreshold {
+		t.Errorf("expected the default %v, got %v", defaultVoteSkipThreshold, threshold)
+	}
+	guildConfig.VoteSkipThreshold = 0.75
+	if threshold := guildConfig.GetVoteSkipThreshold(); threshold != 0.75 {
+		t.Errorf("expected the override 0.75, got %v", threshold)
+	}
+}
+
+func TestGuildConfig_IsChannelAllowedWithEmptyAllowListAllowsEverything(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	if !guildConfig.IsChannelAllowed("any-channel") {
+		t.Error("expected an empty allow-list to allow every channel")
+	}
+}
+
+func TestGuildConfig_AddAllowedChannelRestrictsToBoundChannels(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	guildConfig.AddAllowedChannel("channel-a")
+
+	if !guildConfig.IsChannelAllowed("channel-a") {
+		t.Error("expected the bound channel to be allowed")
+	}
+	if guildConfig.IsChannelAllowed("channel-b") {
+		t.Error("expected an unbound channel to be disallowed once the allow-list is non-empty")
+	}
+}
+
+func TestGuildConfig_AddAllowedChannelRejectsDuplicates(t *testing.T) {
+	withTestConfig("!", 10)
+	guildConfig := &GuildConfig{}
+	if !guildConfig.AddAllowedChannel("channel-a") {
+		t.Fatal("expected the first bind to succeed")
+	}
+	if guildConfig.AddAllowedChannel("channel-a") {
+		t.Error("expected binding the same channel twice to report false")
+	}
+	if len(guildConfig.AllowedChannelIds) != 1 {
+		t.Errorf("expected no duplicate entry, got %v", guildConfig.AllowedChannelIds)
+	}
+}
+
+func TestConfig_GuildConfigCreatesDefaultOnFirstAccess(t *testing.T) {
+	withTestConfig("!", 10)
+	first := Get().GuildConfig("guild-1")
+	second := Get().GuildConfig("guild-1")
+	if first != second {
+		t.Error("expected repeated lookups of the same guild to return the same GuildConfig")
+	}
+}
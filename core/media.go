@@ -0,0 +1,17 @@
+package core
+
+// Media represents a single playable track that has been resolved down to a
+// local audio file on disk.
+type Media struct {
+	Title    string
+	FilePath string
+}
+
+// NewMedia creates a Media pointing at the audio file already present on disk
+// at filePath.
+func NewMedia(title, filePath string) *Media {
+	return &Media{
+		Title:    title,
+		FilePath: filePath,
+	}
+}
@@ -0,0 +1,25 @@
+package core
+
+// SearchResult is a single track resolved by a Source, ready to be
+// downloaded. Id must be unique across every Source a registry might
+// register, since it doubles as the mediacache key; Url is only populated
+// when the result was resolved directly from a link rather than a search
+// query.
+type SearchResult struct {
+	Id    string
+	Title string
+	Url   string
+}
+
+// Source resolves search queries or links down to a playable Media file.
+// youtube.Service, soundcloud.Service and direct.Service all implement it,
+// which is what lets HandleYoutubeCommand, HandleSoundCloudCommand and
+// HandlePlayCommand share the same enqueue path.
+type Source interface {
+	// Search returns the single best match for query, which may be
+	// free-text or a source-specific link.
+	Search(query string) (*SearchResult, error)
+	// Download fetches the audio for result and returns a Media pointing
+	// at the downloaded file.
+	Download(result *SearchResult) (*Media, error)
+}
@@ -0,0 +1,77 @@
+package direct
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// sourcePrefix namespaces the URLs this package hands out as
+// core.SearchResult.Id, so they can't collide with IDs from other sources
+// sharing the same mediacache.
+const sourcePrefix = "direct:"
+
+// supportedExtensions are the raw audio file extensions this source will
+// fetch directly, without any further conversion.
+var supportedExtensions = []string{".mp3", ".opus", ".ogg"}
+
+// Service downloads audio linked to directly over http(s), skipping the
+// search/metadata step every other source needs.
+type Service struct{}
+
+// NewService creates a Service.
+func NewService() *Service {
+	return &Service{}
+}
+
+// IsDirectLink reports whether rawUrl points directly at a supported audio
+// file, judging purely by its scheme and extension.
+func IsDirectLink(rawUrl string) bool {
+	if !strings.HasPrefix(rawUrl, "http://") && !strings.HasPrefix(rawUrl, "https://") {
+		return false
+	}
+	lower := strings.ToLower(rawUrl)
+	for _, ext := range supportedExtensions {
+		if strings.HasSuffix(lower, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search validates that query is a direct link to a supported audio file
+// and wraps it in a SearchResult; it never performs any actual searching.
+func (s *Service) Search(query string) (*core.SearchResult, error) {
+	if !IsDirectLink(query) {
+		return nil, fmt.Errorf("\"%s\" is not a direct link to an mp3, opus or ogg file", query)
+	}
+	return &core.SearchResult{Id: sourcePrefix + query, Title: path.Base(query), Url: query}, nil
+}
+
+// Download fetches the file at result.Url and writes it to a local file.
+func (s *Service) Download(result *core.SearchResult) (*core.Media, error) {
+	response, err := http.Get(result.Url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download \"%s\": %s", result.Url, err.Error())
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download \"%s\": got status %s", result.Url, response.Status)
+	}
+
+	filePath := path.Base(result.Url)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create \"%s\": %s", filePath, err.Error())
+	}
+	defer file.Close()
+	if _, err := io.Copy(file, response.Body); err != nil {
+		return nil, fmt.Errorf("failed to write \"%s\": %s", filePath, err.Error())
+	}
+	return core.NewMedia(result.Title, filePath), nil
+}
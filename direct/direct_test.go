@@ -0,0 +1,33 @@
+package direct
+
+import "testing"
+
+func TestIsDirectLink_AcceptsSupportedExtensions(t *testing.T) {
+	for _, url := range []string{
+		"https://example.com/song.mp3",
+		"http://example.com/song.opus",
+		"https://example.com/path/to/song.OGG",
+	} {
+		if !IsDirectLink(url) {
+			t.Errorf("expected %q to be recognized as a direct link", url)
+		}
+	}
+}
+
+func TestIsDirectLink_RejectsUnsupportedExtension(t *testing.T) {
+	if IsDirectLink("https://example.com/song.wav") {
+		t.Error("expected an unsupported extension to be rejected")
+	}
+}
+
+func TestIsDirectLink_RejectsMissingScheme(t *testing.T) {
+	if IsDirectLink("example.com/song.mp3") {
+		t.Error("expected a url without http(s):// to be rejected")
+	}
+}
+
+func TestIsDirectLink_RejectsPlainQuery(t *testing.T) {
+	if IsDirectLink("never gonna give you up") {
+		t.Error("expected a plain-text query to be rejected")
+	}
+}
@@ -0,0 +1,22 @@
+package ffmpeg
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// ConvertVideoToAudio extracts the audio track of the video at media.FilePath
+// and overwrites media.FilePath with the path to the resulting mp3.
+func ConvertVideoToAudio(media *core.Media) error {
+	audioFilePath := strings.TrimSuffix(media.FilePath, ".mp4") + ".mp3"
+	cmd := exec.Command("ffmpeg", "-i", media.FilePath, "-vn", "-acodec", "libmp3lame", "-y", audioFilePath)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to convert video to audio: %s: %s", err.Error(), string(output))
+	}
+	media.FilePath = audioFilePath
+	return nil
+}
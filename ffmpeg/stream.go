@@ -0,0 +1,91 @@
+package ffmpeg
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+	"layeh.com/gopus"
+)
+
+const (
+	channels  = 2
+	frameRate = 48000
+	frameSize = 960 // 20ms of audio at 48kHz
+)
+
+// Stream decodes a Media file into consecutive Opus-encoded frames suitable
+// for writing directly to a discordgo voice connection's OpusSend channel.
+// It is seek-aware: NewStream can be asked to start decoding partway through
+// the file, which is how seeking is implemented.
+type Stream struct {
+	cmd     *exec.Cmd
+	stdout  io.ReadCloser
+	reader  *bufio.Reader
+	encoder *gopus.Encoder
+	elapsed time.Duration
+}
+
+// NewStream starts an ffmpeg process that decodes media to raw PCM beginning
+// at startAt.
+func NewStream(media *core.Media, startAt time.Duration) (*Stream, error) {
+	encoder, err := gopus.NewEncoder(frameRate, channels, gopus.Audio)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create opus encoder: %s", err.Error())
+	}
+	cmd := exec.Command("ffmpeg",
+		"-ss", fmt.Sprintf("%.3f", startAt.Seconds()),
+		"-i", media.FilePath,
+		"-f", "s16le",
+		"-ar", fmt.Sprintf("%d", frameRate),
+		"-ac", fmt.Sprintf("%d", channels),
+		"pipe:1",
+	)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ffmpeg stdout: %s", err.Error())
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %s", err.Error())
+	}
+	return &Stream{
+		cmd:     cmd,
+		stdout:  stdout,
+		reader:  bufio.NewReaderSize(stdout, 16384),
+		encoder: encoder,
+		elapsed: startAt,
+	}, nil
+}
+
+// ReadFrame blocks until the next 20ms Opus frame is ready, returning io.EOF
+// once the underlying ffmpeg process has no more audio to give.
+func (s *Stream) ReadFrame() ([]byte, error) {
+	pcm := make([]int16, frameSize*channels)
+	if err := binary.Read(s.reader, binary.LittleEndian, &pcm); err != nil {
+		return nil, err
+	}
+	opusFrame, err := s.encoder.Encode(pcm, frameSize, frameSize*2)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode opus frame: %s", err.Error())
+	}
+	s.elapsed += 20 * time.Millisecond
+	return opusFrame, nil
+}
+
+// Position returns how far into the track this stream has decoded.
+func (s *Stream) Position() time.Duration {
+	return s.elapsed
+}
+
+// Close terminates the underlying ffmpeg process.
+func (s *Stream) Close() error {
+	_ = s.stdout.Close()
+	if s.cmd.Process != nil {
+		_ = s.cmd.Process.Kill()
+	}
+	return s.cmd.Wait()
+}
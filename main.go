@@ -4,38 +4,80 @@ import (
 	"errors"
 	"fmt"
 	"github.com/TwinProduction/discord-music-bot/config"
-	"github.com/TwinProduction/discord-music-bot/core"
-	"github.com/TwinProduction/discord-music-bot/ffmpeg"
+	"github.com/TwinProduction/discord-music-bot/direct"
+	"github.com/TwinProduction/discord-music-bot/mediacache"
+	"github.com/TwinProduction/discord-music-bot/player"
+	"github.com/TwinProduction/discord-music-bot/soundcloud"
 	"github.com/TwinProduction/discord-music-bot/youtube"
 	"github.com/bwmarrin/discordgo"
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
-)
-
-const (
-	CommandPrefix = "!"
-	MaxQueueSize  = 10
+	"time"
 )
 
 var (
 	ErrUserNotInVoiceChannel = errors.New("couldn't find voice channel with user in it")
+	ErrNothingPlaying        = errors.New("nothing is playing right now")
+	ErrMissingDJRole         = errors.New("you need the DJ role to do that")
+	ErrMissingAdminPerms     = errors.New("you need administrator permissions to do that")
+	ErrPlayerBusy            = errors.New("the player is busy with another command, try again")
+	ErrVoterNotInBotChannel  = errors.New("you need to be in the bot's voice channel to vote")
 
-	queues      = make(map[string]chan *core.Media)
-	queuesMutex = sync.RWMutex{}
+	guildPlayers      = make(map[string]*player.GuildPlayer)
+	guildPlayersMutex = sync.RWMutex{}
 
 	// guildNames is a mapping between guild id and guild name
 	guildNames = make(map[string]string)
 
-	youtubeService *youtube.Service
+	youtubeService    *youtube.Service
+	soundcloudService *soundcloud.Service
+	directService     *direct.Service
+	mediaCache        *mediacache.Cache
+
+	// commandHandlers maps a lowercased command name (without the prefix) to
+	// the function that handles it.
+	commandHandlers = map[string]func(bot *discordgo.Session, message *discordgo.MessageCreate, query string){
+		"youtube":    HandleYoutubeCommand,
+		"yt":         HandleYoutubeCommand,
+		"sc":         HandleSoundCloudCommand,
+		"play":       HandlePlayCommand,
+		"pause":      HandlePauseCommand,
+		"resume":     HandleResumeCommand,
+		"skip":       HandleSkipCommand,
+		"voteskip":   HandleVoteSkipCommand,
+		"stop":       HandleStopCommand,
+		"queue":      HandleQueueCommand,
+		"np":         HandleNowPlayingCommand,
+		"nowplaying": HandleNowPlayingCommand,
+		"loop":       HandleLoopCommand,
+		"remove":     HandleRemoveCommand,
+		"clear":      HandleClearCommand,
+		"seek":       HandleSeekCommand,
+		"prefix":     HandlePrefixCommand,
+		"djrole":     HandleDJRoleCommand,
+		"bind":       HandleBindCommand,
+	}
 )
 
 func main() {
 	config.Load()
 	youtubeService = youtube.NewService(config.Get().YoutubeApiKey)
+	sc, err := soundcloud.NewService()
+	if err != nil {
+		panic(err)
+	}
+	soundcloudService = sc
+	directService = direct.NewService()
+	cache, err := mediacache.New(config.Get().CacheDir, config.Get().CacheMaxBytes)
+	if err != nil {
+		panic(err)
+	}
+	mediaCache = cache
 	bot, err := Connect(config.Get().DiscordToken)
 	if err != nil {
 		panic(err)
@@ -43,6 +85,7 @@ func main() {
 	defer bot.Close()
 
 	bot.AddHandler(HandleMessage)
+	bot.AddHandler(HandleVoiceStateUpdate)
 	log.Println("Connected successfully")
 
 	// Wait for the bot to be killed
@@ -53,26 +96,39 @@ func main() {
 	log.Println("Terminating bot")
 }
 
+// configCommands are exempt from the channel allow-list, since otherwise
+// binding the bot to one channel would permanently lock every other channel
+// out of ever running !bind, !prefix or !djrole again.
+var configCommands = map[string]bool{
+	"bind":   true,
+	"prefix": true,
+	"djrole": true,
+}
+
 func HandleMessage(bot *discordgo.Session, message *discordgo.MessageCreate) {
 	if message.Author.Bot || message.Author.ID == bot.State.User.ID {
 		return
 	}
-	if strings.HasPrefix(message.Content, CommandPrefix) {
-		command := strings.Replace(strings.Split(message.Content, " ")[0], CommandPrefix, "", 1)
-		query := strings.TrimSpace(strings.Replace(message.Content, fmt.Sprintf("%s%s", CommandPrefix, command), "", 1))
-		command = strings.ToLower(command)
-		if command == "youtube" || command == "yt" {
-			HandleYoutubeCommand(bot, message, query)
-			return
-		}
+	guildConfig := config.Get().GuildConfig(message.GuildID)
+	prefix := guildConfig.GetPrefix()
+	if !strings.HasPrefix(message.Content, prefix) {
+		return
+	}
+	command := strings.Replace(strings.Split(message.Content, " ")[0], prefix, "", 1)
+	query := strings.TrimSpace(strings.Replace(message.Content, fmt.Sprintf("%s%s", prefix, command), "", 1))
+	command = strings.ToLower(command)
+	if !configCommands[command] && !guildConfig.IsChannelAllowed(message.ChannelID) {
+		return
+	}
+	handler, ok := commandHandlers[command]
+	if !ok {
+		return
 	}
+	handler(bot, message, query)
 }
 
 func HandleYoutubeCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
-	if len(queues[message.GuildID]) >= MaxQueueSize {
-		_, _ = bot.ChannelMessageSend(message.ChannelID, "The queue is full!")
-		return
-	}
+	guildPlayer, createNewWorker := getOrCreateGuildPlayer(message.GuildID)
 	guildName := GetGuildNameById(bot, message.GuildID)
 
 	// Find the voice channel the user is in
@@ -84,70 +140,309 @@ func HandleYoutubeCommand(bot *discordgo.Session, message *discordgo.MessageCrea
 	}
 	log.Printf("[%s] Found user %s in voice channel %s", guildName, message.Author.Username, voiceChannelId)
 
-	// Search for video
-	log.Printf("[%s] Searching for \"%s\"", guildName, query)
-	result, err := youtubeService.Search(query)
+	if playlistId, ok := youtube.ExtractPlaylistId(query); ok {
+		log.Printf("[%s] Detected playlist %s, resolving incrementally", guildName, playlistId)
+		HandlePlaylistEnqueue(bot, message, guildPlayer, guildName, voiceChannelId, playlistId, createNewWorker)
+		return
+	}
+
+	resolveAndEnqueue(bot, message, guildPlayer, guildName, voiceChannelId, youtubeService, query, createNewWorker)
+}
+
+func HandlePauseCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandPause}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":pause_button: Paused")
+}
+
+func HandleResumeCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandResume}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":arrow_forward: Resumed")
+}
+
+func HandleSkipCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	if !userMayManagePlayback(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingDJRole.Error())
+		return
+	}
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandSkip}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":track_next: Skipped")
+}
+
+func HandleStopCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	if !userMayManagePlayback(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingDJRole.Error())
+		return
+	}
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	guildPlayer.Clear()
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandStop}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":stop_button: Stopped and cleared the queue")
+}
+
+func HandleQueueCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	queue := guildPlayer.Queue()
+	if len(queue) == 0 {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "The queue is empty")
+		return
+	}
+	var builder strings.Builder
+	builder.WriteString("Queue:\n")
+	for i, media := range queue {
+		builder.WriteString(fmt.Sprintf("%d. %s\n", i+1, media.Title))
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, builder.String())
+}
+
+func HandleNowPlayingCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	state := guildPlayer.State()
+	if state.Current == nil {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	status := ""
+	if state.Paused {
+		status = " (paused)"
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":musical_note: Now playing \"%s\" at %s%s \xe2\x80\xa2 loop: %s", state.Current.Title, formatDuration(state.Elapsed), status, state.Loop.String()))
+}
+
+func HandleLoopCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	var loop player.LoopMode
+	switch strings.ToLower(strings.TrimSpace(query)) {
+	case "track":
+		loop = player.LoopTrack
+	case "queue":
+		loop = player.LoopQueue
+	case "off", "":
+		loop = player.LoopOff
+	default:
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "Usage: !loop [track|queue|off]")
+		return
+	}
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandSetLoop, Loop: loop}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":repeat: Loop set to %s", loop.String()))
+}
+
+func HandleRemoveCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	position, err := strconv.Atoi(strings.TrimSpace(query))
 	if err != nil {
-		log.Printf("[%s] Failed to search for video: %s", guildName, err.Error())
-		_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to search for video: %s", err.Error()))
-		return
-	}
-	log.Printf("[%s] Found video titled \"%s\" from query \"%s\"", guildName, result.Title, query)
-
-	var media *core.Media
-	// Check if the media already exists
-	_, err = os.Stat(fmt.Sprintf("%s.mp3", result.VideoId))
-	if err == nil && os.IsNotExist(err) {
-		media = core.NewMedia(result.Title, fmt.Sprintf("%s.mp3", result.VideoId))
-		log.Printf("[%s] Skipping download because media titled \"%s\" is already present at \"%s\"", guildName, result.Title, media.FilePath)
-	} else {
-		// Download the video
-		log.Printf("[%s] Downloading video with title \"%s\"", guildName, result.Title)
-		media, err = youtubeService.Download(result)
-		if err != nil {
-			log.Printf("[%s] Failed to download video: %s", guildName, err.Error())
-			_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to search for video based on query \"%s\"", query))
-			return
-		}
-		log.Printf("[%s] Downloaded video with title \"%s\" at \"%s\"", guildName, media.Title, media.FilePath)
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "Usage: !remove <pos>")
+		return
+	}
+	media, err := guildPlayer.Remove(position)
+	if err != nil {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, err.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":x: Removed \"%s\" from the queue", media.Title))
+}
 
-		// Convert video to audio
-		log.Printf("[%s] Extracting audio from video with title \"%s\"", guildName, result.Title)
-		err = ffmpeg.ConvertVideoToAudio(media)
-		if err != nil {
-			log.Printf("[%s] Failed to convert video to audio: %s", guildName, err.Error())
-			_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to convert video to audio: %s", err.Error()))
-			_ = os.Remove(media.FilePath)
-			return
+func HandleClearCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	if !userMayManagePlayback(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingDJRole.Error())
+		return
+	}
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	guildPlayer.Clear()
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":wastebasket: Cleared the queue")
+}
+
+func HandleSeekCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	seek, err := time.ParseDuration(strings.TrimSpace(query))
+	if err != nil {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "Usage: !seek <duration>, e.g. !seek 1m30s")
+		return
+	}
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandSeek, Seek: seek}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":fast_forward: Seeking to %s", formatDuration(seek)))
+}
+
+// getOrCreateGuildPlayer returns the GuildPlayer for guildID, creating one if
+// it doesn't exist yet. The returned bool reports whether a new GuildPlayer
+// was created, which the caller uses to decide whether a worker needs to be
+// started for it.
+func getOrCreateGuildPlayer(guildID string) (*player.GuildPlayer, bool) {
+	guildPlayersMutex.Lock()
+	defer guildPlayersMutex.Unlock()
+	guildPlayer, ok := guildPlayers[guildID]
+	if !ok {
+		guildPlayer = player.NewGuildPlayer()
+		guildPlayers[guildID] = guildPlayer
+		return guildPlayer, true
+	}
+	return guildPlayer, false
+}
+
+// findGuildPlayer returns the GuildPlayer for guildID without creating one.
+func findGuildPlayer(guildID string) (*player.GuildPlayer, bool) {
+	guildPlayersMutex.RLock()
+	defer guildPlayersMutex.RUnlock()
+	guildPlayer, ok := guildPlayers[guildID]
+	return guildPlayer, ok
+}
+
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	minutes := d / time.Minute
+	seconds := (d % time.Minute) / time.Second
+	return fmt.Sprintf("%02d:%02d", minutes, seconds)
+}
+
+func HandlePrefixCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	if !isGuildAdmin(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingAdminPerms.Error())
+		return
+	}
+	prefix := strings.TrimSpace(query)
+	if prefix == "" {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "Usage: !prefix <x>")
+		return
+	}
+	config.Get().GuildConfig(message.GuildID).SetPrefix(prefix)
+	if err := config.Save(); err != nil {
+		log.Printf("Failed to save config: %s", err.Error())
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Prefix set to \"%s\"", prefix))
+}
+
+func HandleDJRoleCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	if !isGuildAdmin(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingAdminPerms.Error())
+		return
+	}
+	roleId := parseRoleMention(strings.TrimSpace(query))
+	if roleId == "" {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "Usage: !djrole <@role>")
+		return
+	}
+	config.Get().GuildConfig(message.GuildID).SetDJRoleId(roleId)
+	if err := config.Save(); err != nil {
+		log.Printf("Failed to save config: %s", err.Error())
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("DJ role set to <@&%s>", roleId))
+}
+
+func HandleBindCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	if !isGuildAdmin(bot, message) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrMissingAdminPerms.Error())
+		return
+	}
+	guildConfig := config.Get().GuildConfig(message.GuildID)
+	if !guildConfig.AddAllowedChannel(message.ChannelID) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "This channel is already bound")
+		return
+	}
+	if err := config.Save(); err != nil {
+		log.Printf("Failed to save config: %s", err.Error())
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, "Bound this channel, the bot will now only respond to commands here")
+}
+
+// userMayManagePlayback reports whether the message author is allowed to
+// skip, stop or clear the queue: either no DJ role is configured, the author
+// has that role, or the author is a server administrator.
+func userMayManagePlayback(bot *discordgo.Session, message *discordgo.MessageCreate) bool {
+	djRoleId := config.Get().GuildConfig(message.GuildID).GetDJRoleId()
+	if djRoleId == "" {
+		return true
+	}
+	if isGuildAdmin(bot, message) {
+		return true
+	}
+	member, err := bot.GuildMember(message.GuildID, message.Author.ID)
+	if err != nil {
+		return false
+	}
+	for _, roleId := range member.Roles {
+		if roleId == djRoleId {
+			return true
 		}
-		log.Printf("[%s] Extracted audio from video with title \"%s\" into \"%s\"", guildName, result.Title, media.FilePath)
-	}
-
-	// Add song to guild queue
-	createNewWorker := false
-	queuesMutex.Lock()
-	defer queuesMutex.Unlock()
-	if queues[message.GuildID] == nil {
-		queues[message.GuildID] = make(chan *core.Media, MaxQueueSize)
-		// If the channel was nil, it means that there was no worker
-		createNewWorker = true
-	}
-	queues[message.GuildID] <- media
-	log.Printf("[%s] Added media with title \"%s\" to queue at position %d", guildName, media.Title, len(queues[message.GuildID]))
-	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":musical_note: Added media with title \"%s\" to queue at position %d", media.Title, len(queues[message.GuildID])))
-
-	if createNewWorker {
-		log.Printf("[%s] Starting worker", guildName)
-		go func() {
-			err = worker(bot, message.GuildID, voiceChannelId)
-			if err != nil {
-				log.Printf("[%s] Failed to start worker: %s", guildName, err.Error())
-				_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to start voice worker: %s", err.Error()))
-				_ = os.Remove(media.FilePath)
-				return
-			}
-		}()
 	}
+	return false
+}
+
+// isGuildAdmin reports whether the message author has administrator
+// permissions in the guild the message was sent in.
+func isGuildAdmin(bot *discordgo.Session, message *discordgo.MessageCreate) bool {
+	permissions, err := bot.UserChannelPermissions(message.Author.ID, message.ChannelID)
+	if err != nil {
+		return false
+	}
+	return permissions&discordgo.PermissionAdministrator != 0
+}
+
+// parseRoleMention extracts the role ID out of a Discord role mention like
+// "<@&123456789012345678>", or returns it unchanged if it's already a bare ID.
+func parseRoleMention(mention string) string {
+	mention = strings.TrimPrefix(mention, "<@&")
+	mention = strings.TrimSuffix(mention, ">")
+	return mention
 }
 
 func GetVoiceChannelWhereMessageAuthorIs(bot *discordgo.Session, message *discordgo.MessageCreate) (string, error) {
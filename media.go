@@ -0,0 +1,29 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+	"github.com/TwinProduction/discord-music-bot/mediacache"
+)
+
+// resolveMedia returns a cache Handle for result, downloading it through
+// source only if it isn't already cached. The returned handle must be
+// released once the track is done being queued or played.
+func resolveMedia(source core.Source, result *core.SearchResult, guildName string) (*mediacache.Handle, error) {
+	return mediaCache.GetOrDownload(result.Id, func(id, filePath string) (*core.Media, error) {
+		log.Printf("[%s] Downloading \"%s\"", guildName, result.Title)
+		media, err := source.Download(result)
+		if err != nil {
+			return nil, fmt.Errorf("failed to download \"%s\": %s", result.Title, err.Error())
+		}
+		if err := os.Rename(media.FilePath, filePath); err != nil {
+			return nil, fmt.Errorf("failed to move \"%s\" into cache: %s", result.Title, err.Error())
+		}
+		media.FilePath = filePath
+		log.Printf("[%s] Downloaded \"%s\" into \"%s\"", guildName, result.Title, media.FilePath)
+		return media, nil
+	})
+}
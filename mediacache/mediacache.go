@@ -0,0 +1,260 @@
+package mediacache
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// DefaultMaxBytes is the cache's byte-size budget if none is configured.
+const DefaultMaxBytes = 2 * 1024 * 1024 * 1024 // 2 GiB
+
+const indexFileName = "index.json"
+
+// Downloader resolves id to audio written at filePath.
+type Downloader func(id, filePath string) (*core.Media, error)
+
+// entry is what the Cache tracks per cached file.
+type entry struct {
+	media      *core.Media
+	size       int64
+	lastAccess time.Time
+	refCount   int
+}
+
+// indexEntry is the on-disk representation of an entry, persisted so access
+// times survive a restart.
+type indexEntry struct {
+	Id         string    `json:"id"`
+	Title      string    `json:"title"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"lastAccess"`
+}
+
+// download tracks a single in-flight GetOrDownload call for an id, so
+// concurrent requests for the same id wait on one download instead of each
+// starting their own and racing on the same file.
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// Cache manages a directory of downloaded mp3s, enforcing a total
+// byte-size budget by evicting the least recently used files once it's
+// exceeded. Files referenced by an outstanding Handle are never evicted.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu        sync.Mutex
+	entries   map[string]*entry     // keyed by SearchResult.Id
+	downloads map[string]*download // in-flight downloads, keyed by SearchResult.Id
+}
+
+// New creates a Cache rooted at dir with the given byte-size budget,
+// loading whatever index of previously cached files it finds there. A
+// maxBytes of 0 uses DefaultMaxBytes.
+func New(dir string, maxBytes int64) (*Cache, error) {
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxBytes
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create cache directory: %s", err.Error())
+	}
+	cache := &Cache{
+		dir:       dir,
+		maxBytes:  maxBytes,
+		entries:   make(map[string]*entry),
+		downloads: make(map[string]*download),
+	}
+	if err := cache.loadIndex(); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// Handle is a reference-counted hold on a cached file that keeps it from
+// being evicted while a track is queued or playing. Callers must call
+// Release once they're done with it.
+type Handle struct {
+	cache *Cache
+	entry *entry
+}
+
+// Media returns the Media this handle points at.
+func (h *Handle) Media() *core.Media {
+	return h.entry.media
+}
+
+// Release drops this handle's hold on the cached file.
+func (h *Handle) Release() {
+	h.cache.release(h.entry)
+}
+
+// GetOrDownload returns a Handle for id, calling downloader to fetch it if
+// it isn't already cached. If another call is already downloading id, this
+// waits for that download to finish instead of starting a second one. The
+// returned handle must be released by the caller once the track is done
+// being queued or played.
+func (c *Cache) GetOrDownload(id string, downloader Downloader) (*Handle, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[id]; ok {
+		e.lastAccess = time.Now()
+		e.refCount++
+		if err := c.saveIndexLocked(); err != nil {
+			log.Printf("mediacache: failed to save index: %s", err.Error())
+		}
+		c.mu.Unlock()
+		return &Handle{cache: c, entry: e}, nil
+	}
+	if d, ok := c.downloads[id]; ok {
+		c.mu.Unlock()
+		<-d.done
+		if d.err != nil {
+			return nil, d.err
+		}
+		c.mu.Lock()
+		e, ok := c.entries[id]
+		if ok {
+			e.lastAccess = time.Now()
+			e.refCount++
+		}
+		c.mu.Unlock()
+		if !ok {
+			return nil, fmt.Errorf("download for \"%s\" finished but left no cache entry", id)
+		}
+		return &Handle{cache: c, entry: e}, nil
+	}
+	d := &download{done: make(chan struct{})}
+	c.downloads[id] = d
+	c.mu.Unlock()
+
+	filePath := filepath.Join(c.dir, sanitizeFileName(id)+".mp3")
+	media, err := downloader(id, filePath)
+	if err != nil {
+		c.mu.Lock()
+		delete(c.downloads, id)
+		d.err = err
+		close(d.done)
+		c.mu.Unlock()
+		return nil, err
+	}
+	info, err := os.Stat(media.FilePath)
+	if err != nil {
+		err = fmt.Errorf("failed to stat downloaded file: %s", err.Error())
+		c.mu.Lock()
+		delete(c.downloads, id)
+		d.err = err
+		close(d.done)
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e := &entry{media: media, size: info.Size(), lastAccess: time.Now(), refCount: 1}
+	c.entries[id] = e
+	c.evictLeastRecentlyUsed()
+	if err := c.saveIndexLocked(); err != nil {
+		log.Printf("mediacache: failed to save index: %s", err.Error())
+	}
+	delete(c.downloads, id)
+	close(d.done)
+	return &Handle{cache: c, entry: e}, nil
+}
+
+func (c *Cache) release(e *entry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e.refCount > 0 {
+		e.refCount--
+	}
+}
+
+// evictLeastRecentlyUsed removes entries with a zero refcount, oldest
+// access time first, until the cache is back under its byte-size budget.
+// Must be called with c.mu held.
+func (c *Cache) evictLeastRecentlyUsed() {
+	for c.totalSizeLocked() > c.maxBytes {
+		var oldestId string
+		var oldest *entry
+		for id, e := range c.entries {
+			if e.refCount > 0 {
+				continue
+			}
+			if oldest == nil || e.lastAccess.Before(oldest.lastAccess) {
+				oldestId, oldest = id, e
+			}
+		}
+		if oldest == nil {
+			return // everything left is in use, can't evict any further
+		}
+		_ = os.Remove(oldest.media.FilePath)
+		delete(c.entries, oldestId)
+	}
+}
+
+func (c *Cache) totalSizeLocked() int64 {
+	var total int64
+	for _, e := range c.entries {
+		total += e.size
+	}
+	return total
+}
+
+func (c *Cache) indexPath() string {
+	return filepath.Join(c.dir, indexFileName)
+}
+
+func (c *Cache) loadIndex() error {
+	data, err := os.ReadFile(c.indexPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read cache index: %s", err.Error())
+	}
+	var indexEntries []indexEntry
+	if err := json.Unmarshal(data, &indexEntries); err != nil {
+		return fmt.Errorf("failed to parse cache index: %s", err.Error())
+	}
+	for _, ie := range indexEntries {
+		filePath := filepath.Join(c.dir, sanitizeFileName(ie.Id)+".mp3")
+		if _, err := os.Stat(filePath); err != nil {
+			continue // file was removed out from under us since the index was saved
+		}
+		c.entries[ie.Id] = &entry{
+			media:      core.NewMedia(ie.Title, filePath),
+			size:       ie.Size,
+			lastAccess: ie.LastAccess,
+		}
+	}
+	return nil
+}
+
+// saveIndexLocked persists the current entries to disk. Must be called with
+// c.mu held.
+func (c *Cache) saveIndexLocked() error {
+	indexEntries := make([]indexEntry, 0, len(c.entries))
+	for id, e := range c.entries {
+		indexEntries = append(indexEntries, indexEntry{Id: id, Title: e.media.Title, Size: e.size, LastAccess: e.lastAccess})
+	}
+	data, err := json.MarshalIndent(indexEntries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cache index: %s", err.Error())
+	}
+	return os.WriteFile(c.indexPath(), data, 0644)
+}
+
+// sanitizeFileName makes id safe to use as a file name, since ids coming
+// from URL-based sources may contain path separators.
+func sanitizeFileName(id string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(id)
+}
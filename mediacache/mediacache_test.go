@@ -0,0 +1,196 @@
+package mediacache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// sizedDownloader returns a Downloader that writes size bytes to filePath
+// and counts how many times it was actually invoked.
+func sizedDownloader(size int) (Downloader, *int32) {
+	var calls int32
+	return func(id, filePath string) (*core.Media, error) {
+		atomic.AddInt32(&calls, 1)
+		if err := os.WriteFile(filePath, make([]byte, size), 0644); err != nil {
+			return nil, err
+		}
+		return core.NewMedia("title-"+id, filePath), nil
+	}, &calls
+}
+
+func TestCache_GetOrDownloadCachesAfterFirstDownload(t *testing.T) {
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	downloader, calls := sizedDownloader(10)
+
+	handle1, err := cache.GetOrDownload("track-1", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	handle2, err := cache.GetOrDownload("track-1", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if atomic.LoadInt32(calls) != 1 {
+		t.Errorf("expected the downloader to run once, got %d", *calls)
+	}
+	if handle1.Media().FilePath != handle2.Media().FilePath {
+		t.Error("expected both handles to point at the same file")
+	}
+}
+
+func TestCache_GetOrDownloadDedupsConcurrentDownloads(t *testing.T) {
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	start := make(chan struct{})
+	var calls int32
+	downloader := func(id, filePath string) (*core.Media, error) {
+		atomic.AddInt32(&calls, 1)
+		<-start // hold the download open so callers arriving meanwhile find it in flight
+		if err := os.WriteFile(filePath, make([]byte, 10), 0644); err != nil {
+			return nil, err
+		}
+		return core.NewMedia("title", filePath), nil
+	}
+
+	const concurrency = 10
+	var wg sync.WaitGroup
+	errs := make([]error, concurrency)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = cache.GetOrDownload("track-1", downloader)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("caller %d got an unexpected error: %s", i, err.Error())
+		}
+	}
+	if atomic.LoadInt32(&calls) != 1 {
+		t.Errorf("expected exactly 1 download despite %d concurrent callers, got %d", concurrency, calls)
+	}
+}
+
+func TestCache_ReleaseAllowsEviction(t *testing.T) {
+	cache, err := New(t.TempDir(), 15) // budget only fits one 10-byte file at a time
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	downloader, _ := sizedDownloader(10)
+
+	handle1, err := cache.GetOrDownload("track-1", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	handle1.Release()
+
+	handle2, err := cache.GetOrDownload("track-2", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer handle2.Release()
+
+	if _, err := os.Stat(handle1.Media().FilePath); !os.IsNotExist(err) {
+		t.Error("expected track-1's file to have been evicted once it was released and the budget was exceeded")
+	}
+}
+
+func TestCache_HeldHandleIsNeverEvicted(t *testing.T) {
+	cache, err := New(t.TempDir(), 15) // budget only fits one 10-byte file at a time
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	downloader, _ := sizedDownloader(10)
+
+	handle1, err := cache.GetOrDownload("track-1", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	defer handle1.Release()
+
+	if _, err := cache.GetOrDownload("track-2", downloader); err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+
+	if _, err := os.Stat(handle1.Media().FilePath); err != nil {
+		t.Error("expected track-1's file to survive eviction while its handle is still held")
+	}
+}
+
+func TestCache_IndexPersistsTitleAcrossReload(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	downloader := func(id, filePath string) (*core.Media, error) {
+		if err := os.WriteFile(filePath, make([]byte, 10), 0644); err != nil {
+			return nil, err
+		}
+		return core.NewMedia("Never Gonna Give You Up", filePath), nil
+	}
+	handle, err := cache.GetOrDownload("track-1", downloader)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	handle.Release() // drop the refcount so nothing is held when we reload
+
+	reloaded, err := New(dir, 0)
+	if err != nil {
+		t.Fatalf("unexpected error reloading cache: %s", err.Error())
+	}
+	restoredHandle, err := reloaded.GetOrDownload("track-1", func(id, filePath string) (*core.Media, error) {
+		return nil, fmt.Errorf("downloader should not run for an entry restored from the index")
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if restoredHandle.Media().Title != "Never Gonna Give You Up" {
+		t.Errorf("expected the title to survive a restart, got %q", restoredHandle.Media().Title)
+	}
+}
+
+func TestCache_GetOrDownloadPropagatesDownloaderError(t *testing.T) {
+	cache, err := New(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	wantErr := fmt.Errorf("boom")
+	_, err = cache.GetOrDownload("track-1", func(id, filePath string) (*core.Media, error) {
+		return nil, wantErr
+	})
+	if err != wantErr {
+		t.Errorf("expected the downloader's error to propagate, got %v", err)
+	}
+
+	// A failed download must not leave a stale in-flight entry behind that
+	// would make every future call for the same id hang forever.
+	handle, err := cache.GetOrDownload("track-1", func(id, filePath string) (*core.Media, error) {
+		if err := os.WriteFile(filePath, make([]byte, 10), 0644); err != nil {
+			return nil, err
+		}
+		return core.NewMedia("title", filePath), nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error on retry: %s", err.Error())
+	}
+	if _, err := os.Stat(filepath.Dir(handle.Media().FilePath)); err != nil {
+		t.Errorf("unexpected error stat-ing cache dir: %s", err.Error())
+	}
+}
@@ -0,0 +1,311 @@
+package player
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// Command is an instruction sent down a GuildPlayer's control channel to
+// interrupt whatever the worker goroutine is currently doing.
+type Command int
+
+const (
+	CommandPause Command = iota
+	CommandResume
+	CommandSkip
+	CommandStop
+	CommandSeek
+	CommandSetLoop
+)
+
+// LoopMode controls what happens once the current track finishes.
+type LoopMode int
+
+const (
+	LoopOff LoopMode = iota
+	LoopTrack
+	LoopQueue
+)
+
+func (l LoopMode) String() string {
+	switch l {
+	case LoopTrack:
+		return "track"
+	case LoopQueue:
+		return "queue"
+	default:
+		return "off"
+	}
+}
+
+// ControlMessage is what gets sent down GuildPlayer.Control to the worker.
+type ControlMessage struct {
+	Command Command
+	Seek    time.Duration
+	Loop    LoopMode
+}
+
+// State is a snapshot of what a GuildPlayer is doing, used to answer
+// !nowplaying.
+type State struct {
+	Current *core.Media
+	Elapsed time.Duration
+	Paused  bool
+	Loop    LoopMode
+}
+
+// queueItem pairs a Media with the function that releases whatever hold
+// (e.g. a mediacache refcount) is keeping its file on disk. release is nil
+// for media that isn't backed by a cache.
+type queueItem struct {
+	media   *core.Media
+	release func()
+}
+
+// GuildPlayer holds the mutable playback state for a single guild: the
+// pending queue, the currently playing track, and the control channel the
+// worker goroutine listens on so commands can interrupt playback mid-song.
+type GuildPlayer struct {
+	Control chan ControlMessage
+
+	mu             sync.Mutex
+	queue          []*queueItem
+	current        *queueItem
+	elapsed        time.Duration
+	paused         bool
+	loop           LoopMode
+	voiceChannelId string
+	producers      int
+	stopped        chan struct{}
+}
+
+// NewGuildPlayer creates an empty GuildPlayer ready to have tracks enqueued
+// onto it.
+func NewGuildPlayer() *GuildPlayer {
+	return &GuildPlayer{
+		Control: make(chan ControlMessage, 1),
+		stopped: make(chan struct{}),
+	}
+}
+
+// Enqueue appends media to the queue and returns its 1-indexed position.
+func (p *GuildPlayer) Enqueue(media *core.Media) int {
+	return p.EnqueueWithRelease(media, nil)
+}
+
+// EnqueueWithRelease appends media to the queue along with a release
+// function that's called once the track is done being queued or played
+// (e.g. to drop a mediacache refcount). It returns the track's 1-indexed
+// position.
+func (p *GuildPlayer) EnqueueWithRelease(media *core.Media, release func()) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.queue = append(p.queue, &queueItem{media: media, release: release})
+	return len(p.queue)
+}
+
+// BeginProducing records that a goroutine is actively resolving tracks to
+// enqueue (e.g. a playlist still paging through results), so HasPendingProducers
+// can tell the worker not to tear down on a momentarily empty queue.
+func (p *GuildPlayer) BeginProducing() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.producers++
+}
+
+// EndProducing marks a producer started with BeginProducing as finished.
+func (p *GuildPlayer) EndProducing() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.producers--
+}
+
+// HasPendingProducers reports whether any producer registered with
+// BeginProducing is still running.
+func (p *GuildPlayer) HasPendingProducers() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.producers > 0
+}
+
+// MarkStopped signals, via the channel IsStopped checks, that this
+// GuildPlayer is no longer being serviced by a worker and producers should
+// stop enqueuing onto it. Safe to call more than once.
+func (p *GuildPlayer) MarkStopped() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	select {
+	case <-p.stopped:
+	default:
+		close(p.stopped)
+	}
+}
+
+// IsStopped reports whether MarkStopped has been called.
+func (p *GuildPlayer) IsStopped() bool {
+	select {
+	case <-p.stopped:
+		return true
+	default:
+		return false
+	}
+}
+
+// Next pops the next track off the queue, taking the current loop mode into
+// account: LoopTrack replays the track that just finished, LoopQueue pushes
+// it back to the end of the queue before popping the new head. The track
+// that just finished has its release function called unless it's being
+// looped.
+func (p *GuildPlayer) Next() *core.Media {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.loop == LoopTrack && p.current != nil {
+		p.elapsed = 0
+		return p.current.media
+	}
+	finished := p.current
+	if p.loop == LoopQueue && finished != nil {
+		p.queue = append(p.queue, finished)
+	} else if finished != nil && finished.release != nil {
+		finished.release()
+	}
+	if len(p.queue) == 0 {
+		p.current = nil
+		return nil
+	}
+	p.current = p.queue[0]
+	p.queue = p.queue[1:]
+	p.elapsed = 0
+	return p.current.media
+}
+
+// ReleaseCurrent releases the handle held by whatever is currently playing.
+// Used when playback is stopped entirely, since Next is never called again
+// to do it. Safe to call even if nothing is playing.
+func (p *GuildPlayer) ReleaseCurrent() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current != nil && p.current.release != nil {
+		p.current.release()
+		p.current.release = nil
+	}
+}
+
+// Queue returns a copy of the pending tracks, not including the one
+// currently playing.
+func (p *GuildPlayer) Queue() []*core.Media {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	queue := make([]*core.Media, len(p.queue))
+	for i, item := range p.queue {
+		queue[i] = item.media
+	}
+	return queue
+}
+
+// Len returns the number of tracks waiting behind the one currently playing.
+func (p *GuildPlayer) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.queue)
+}
+
+// Remove deletes the track at the given 1-indexed position from the queue,
+// releases its handle and returns it.
+func (p *GuildPlayer) Remove(position int) (*core.Media, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if position < 1 || position > len(p.queue) {
+		return nil, fmt.Errorf("no track at position %d", position)
+	}
+	item := p.queue[position-1]
+	p.queue = append(p.queue[:position-1], p.queue[position:]...)
+	if item.release != nil {
+		item.release()
+	}
+	return item.media, nil
+}
+
+// Clear empties the queue, releasing every queued track's handle, without
+// touching the currently playing track.
+func (p *GuildPlayer) Clear() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, item := range p.queue {
+		if item.release != nil {
+			item.release()
+		}
+	}
+	p.queue = nil
+}
+
+// SetElapsed records how far into the current track playback has progressed.
+// The worker goroutine calls this as it streams frames.
+func (p *GuildPlayer) SetElapsed(elapsed time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.elapsed = elapsed
+}
+
+// SetPaused records whether playback is currently paused.
+func (p *GuildPlayer) SetPaused(paused bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.paused = paused
+}
+
+// SetLoop changes the loop mode.
+func (p *GuildPlayer) SetLoop(loop LoopMode) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loop = loop
+}
+
+// SetVoiceChannelId records the voice channel the bot actually joined to
+// play for this guild, so quorum-sensitive commands like !voteskip can
+// count members of that channel instead of trusting wherever the caller
+// happens to be sitting.
+func (p *GuildPlayer) SetVoiceChannelId(voiceChannelId string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.voiceChannelId = voiceChannelId
+}
+
+// VoiceChannelId returns the voice channel the bot joined to play for this
+// guild, or an empty string if the worker hasn't joined one yet.
+func (p *GuildPlayer) VoiceChannelId() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.voiceChannelId
+}
+
+// State returns a snapshot of the current playback state.
+func (p *GuildPlayer) State() State {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var current *core.Media
+	if p.current != nil {
+		current = p.current.media
+	}
+	return State{
+		Current: current,
+		Elapsed: p.elapsed,
+		Paused:  p.paused,
+		Loop:    p.loop,
+	}
+}
+
+// Send delivers a control message to the worker, reporting false instead of
+// blocking if the worker hasn't drained the previous one yet. Callers must
+// check this and tell the user to retry rather than assuming delivery.
+func (p *GuildPlayer) Send(message ControlMessage) bool {
+	select {
+	case p.Control <- message:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,175 @@
+package player
+
+import (
+	"testing"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+func TestGuildPlayer_EnqueueReturnsPosition(t *testing.T) {
+	p := NewGuildPlayer()
+	if pos := p.Enqueue(core.NewMedia("a", "a.mp3")); pos != 1 {
+		t.Errorf("expected position 1, got %d", pos)
+	}
+	if pos := p.Enqueue(core.NewMedia("b", "b.mp3")); pos != 2 {
+		t.Errorf("expected position 2, got %d", pos)
+	}
+}
+
+func TestGuildPlayer_NextPopsInOrder(t *testing.T) {
+	p := NewGuildPlayer()
+	p.Enqueue(core.NewMedia("a", "a.mp3"))
+	p.Enqueue(core.NewMedia("b", "b.mp3"))
+
+	if media := p.Next(); media == nil || media.Title != "a" {
+		t.Fatalf("expected \"a\", got %v", media)
+	}
+	if media := p.Next(); media == nil || media.Title != "b" {
+		t.Fatalf("expected \"b\", got %v", media)
+	}
+	if media := p.Next(); media != nil {
+		t.Fatalf("expected nil once the queue is empty, got %v", media)
+	}
+}
+
+func TestGuildPlayer_NextCallsReleaseOnFinishedTrack(t *testing.T) {
+	p := NewGuildPlayer()
+	released := false
+	p.EnqueueWithRelease(core.NewMedia("a", "a.mp3"), func() { released = true })
+	p.Next() // starts "a" playing
+
+	p.EnqueueWithRelease(core.NewMedia("b", "b.mp3"), nil)
+	p.Next() // finishes "a", starts "b"
+
+	if !released {
+		t.Error("expected release to be called for the finished track")
+	}
+}
+
+func TestGuildPlayer_LoopTrackReplaysCurrent(t *testing.T) {
+	p := NewGuildPlayer()
+	p.Enqueue(core.NewMedia("a", "a.mp3"))
+	p.Next() // starts "a" playing
+	p.SetLoop(LoopTrack)
+
+	if media := p.Next(); media == nil || media.Title != "a" {
+		t.Fatalf("expected LoopTrack to replay \"a\", got %v", media)
+	}
+	if media := p.Next(); media == nil || media.Title != "a" {
+		t.Fatalf("expected LoopTrack to keep replaying \"a\", got %v", media)
+	}
+}
+
+func TestGuildPlayer_LoopQueueRequeuesFinishedTrack(t *testing.T) {
+	p := NewGuildPlayer()
+	p.Enqueue(core.NewMedia("a", "a.mp3"))
+	p.Enqueue(core.NewMedia("b", "b.mp3"))
+	p.SetLoop(LoopQueue)
+
+	first := p.Next()
+	second := p.Next()
+	third := p.Next()
+
+	if first.Title != "a" || second.Title != "b" || third.Title != "a" {
+		t.Fatalf("expected a, b, a; got %s, %s, %s", first.Title, second.Title, third.Title)
+	}
+}
+
+func TestGuildPlayer_RemoveReleasesAndReturnsTrack(t *testing.T) {
+	p := NewGuildPlayer()
+	released := false
+	p.EnqueueWithRelease(core.NewMedia("a", "a.mp3"), func() { released = true })
+	p.Enqueue(core.NewMedia("b", "b.mp3"))
+
+	media, err := p.Remove(1)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err.Error())
+	}
+	if media.Title != "a" {
+		t.Errorf("expected to remove \"a\", got %q", media.Title)
+	}
+	if !released {
+		t.Error("expected release to be called for the removed track")
+	}
+	if p.Len() != 1 {
+		t.Errorf("expected 1 track left, got %d", p.Len())
+	}
+}
+
+func TestGuildPlayer_RemoveOutOfRangeReturnsError(t *testing.T) {
+	p := NewGuildPlayer()
+	p.Enqueue(core.NewMedia("a", "a.mp3"))
+
+	if _, err := p.Remove(0); err == nil {
+		t.Error("expected an error for position 0")
+	}
+	if _, err := p.Remove(2); err == nil {
+		t.Error("expected an error for a position past the end of the queue")
+	}
+}
+
+func TestGuildPlayer_ClearReleasesEveryQueuedTrack(t *testing.T) {
+	p := NewGuildPlayer()
+	releasedCount := 0
+	p.EnqueueWithRelease(core.NewMedia("a", "a.mp3"), func() { releasedCount++ })
+	p.EnqueueWithRelease(core.NewMedia("b", "b.mp3"), func() { releasedCount++ })
+
+	p.Clear()
+
+	if releasedCount != 2 {
+		t.Errorf("expected 2 releases, got %d", releasedCount)
+	}
+	if p.Len() != 0 {
+		t.Errorf("expected an empty queue, got %d", p.Len())
+	}
+}
+
+func TestGuildPlayer_SendReportsFailureWhenControlIsFull(t *testing.T) {
+	p := NewGuildPlayer()
+	if !p.Send(ControlMessage{Command: CommandPause}) {
+		t.Fatal("expected the first send to succeed")
+	}
+	if p.Send(ControlMessage{Command: CommandResume}) {
+		t.Error("expected the second send to report failure since the worker hasn't drained the control channel")
+	}
+}
+
+func TestGuildPlayer_HasPendingProducersTracksOutstandingProducers(t *testing.T) {
+	p := NewGuildPlayer()
+	if p.HasPendingProducers() {
+		t.Fatal("expected no pending producers on a fresh GuildPlayer")
+	}
+
+	p.BeginProducing()
+	if !p.HasPendingProducers() {
+		t.Error("expected HasPendingProducers to report true once a producer has begun")
+	}
+
+	p.BeginProducing()
+	p.EndProducing()
+	if !p.HasPendingProducers() {
+		t.Error("expected HasPendingProducers to stay true while a second producer is still running")
+	}
+
+	p.EndProducing()
+	if p.HasPendingProducers() {
+		t.Error("expected HasPendingProducers to report false once every producer has ended")
+	}
+}
+
+func TestGuildPlayer_MarkStoppedIsIdempotentAndObservable(t *testing.T) {
+	p := NewGuildPlayer()
+	if p.IsStopped() {
+		t.Fatal("expected a fresh GuildPlayer not to be stopped")
+	}
+
+	p.MarkStopped()
+	if !p.IsStopped() {
+		t.Error("expected IsStopped to report true after MarkStopped")
+	}
+
+	p.MarkStopped() // must not panic or block on a second call
+	if !p.IsStopped() {
+		t.Error("expected IsStopped to still report true after a second MarkStopped")
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TwinProduction/discord-music-bot/config"
+	"github.com/TwinProduction/discord-music-bot/player"
+	"github.com/bwmarrin/discordgo"
+)
+
+// progressReportInterval is how often the initial reply message is edited
+// to reflect how many tracks have been queued so far.
+const progressReportInterval = 2 * time.Second
+
+// HandlePlaylistEnqueue resolves playlistId page-by-page in the background,
+// enqueuing each track as soon as its metadata is fetched so the first
+// track can start playing while later pages are still loading. It respects
+// the guild's max queue size by backpressuring the fetcher whenever the
+// queue is full.
+func HandlePlaylistEnqueue(bot *discordgo.Session, message *discordgo.MessageCreate, guildPlayer *player.GuildPlayer, guildName, voiceChannelId, playlistId string, startWorker bool) {
+	total, err := youtubeService.PlaylistSize(playlistId)
+	if err != nil {
+		log.Printf("[%s] Failed to fetch playlist size: %s", guildName, err.Error())
+	}
+	reply, err := bot.ChannelMessageSend(message.ChannelID, ":musical_note: Resolving playlist...")
+	if err != nil {
+		log.Printf("[%s] Failed to send initial playlist reply: %s", guildName, err.Error())
+	}
+
+	maxQueueSize := config.Get().GuildConfig(message.GuildID).GetMaxQueueSize()
+	results, errs := youtubeService.StreamPlaylist(playlistId)
+
+	// abandon stops this goroutine from writing into guildPlayer any further
+	// once it's no longer being serviced by a worker (e.g. the user !stopped
+	// playback mid-resolution), draining whatever's left of results in the
+	// background so youtubeService.StreamPlaylist's producer goroutine isn't
+	// left blocked sending to a channel nobody reads from again.
+	abandon := func() {
+		log.Printf("[%s] Playback was stopped, abandoning playlist resolution", guildName)
+		go func() {
+			for range results {
+			}
+		}()
+	}
+
+	guildPlayer.BeginProducing()
+	go func() {
+		defer guildPlayer.EndProducing()
+
+		added := 0
+		lastReported := time.Now()
+		for result := range results {
+			if guildPlayer.IsStopped() {
+				abandon()
+				return
+			}
+			for guildPlayer.Len() >= maxQueueSize {
+				if guildPlayer.IsStopped() {
+					abandon()
+					return
+				}
+				time.Sleep(500 * time.Millisecond)
+			}
+
+			handle, err := resolveMedia(youtubeService, result, guildName)
+			if err != nil {
+				log.Printf("[%s] Skipping playlist item \"%s\": %s", guildName, result.Title, err.Error())
+				continue
+			}
+			if guildPlayer.IsStopped() {
+				handle.Release()
+				abandon()
+				return
+			}
+			media := handle.Media()
+
+			guildPlayer.EnqueueWithRelease(media, handle.Release)
+			added++
+			log.Printf("[%s] Added playlist item \"%s\" to queue (%d/%d)", guildName, media.Title, added, total)
+
+			if startWorker {
+				startWorker = false
+				log.Printf("[%s] Starting worker", guildName)
+				go func() {
+					if err := worker(bot, message.GuildID, voiceChannelId); err != nil {
+						log.Printf("[%s] Failed to start worker: %s", guildName, err.Error())
+					}
+				}()
+			}
+
+			if reply != nil && time.Since(lastReported) > progressReportInterval {
+				_, _ = bot.ChannelMessageEdit(message.ChannelID, reply.ID, fmt.Sprintf(":musical_note: Added %d/%d tracks...", added, total))
+				lastReported = time.Now()
+			}
+		}
+		if err, ok := <-errs; ok {
+			log.Printf("[%s] Playlist resolution failed: %s", guildName, err.Error())
+		}
+
+		summary := fmt.Sprintf(":musical_note: Added %d/%d tracks from the playlist", added, total)
+		if reply != nil {
+			_, _ = bot.ChannelMessageEdit(message.ChannelID, reply.ID, summary)
+		} else {
+			_, _ = bot.ChannelMessageSend(message.ChannelID, summary)
+		}
+	}()
+}
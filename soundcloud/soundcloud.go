@@ -0,0 +1,73 @@
+package soundcloud
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	scapi "github.com/zackradisic/soundcloud-api"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// sourcePrefix namespaces the track IDs this package hands out as
+// core.SearchResult.Id, so they can't collide with IDs from other sources
+// sharing the same mediacache.
+const sourcePrefix = "sc:"
+
+// Service resolves SoundCloud links and queries to playable Media, using
+// the unofficial SoundCloud API to look up stream URLs and metadata.
+type Service struct {
+	client *scapi.API
+}
+
+// NewService creates a Service backed by a fresh SoundCloud API client.
+func NewService() (*Service, error) {
+	client, err := scapi.New(scapi.APIOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create soundcloud client: %s", err.Error())
+	}
+	return &Service{client: client}, nil
+}
+
+// Search resolves url, a SoundCloud track link, to the track it points at.
+// SoundCloud has no public search endpoint usable without an API key, so
+// unlike youtube.Service.Search this only accepts direct track links.
+func (s *Service) Search(url string) (*core.SearchResult, error) {
+	track, err := s.client.GetTrackInfo(scapi.GetTrackInfoOptions{URL: url})
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve soundcloud track: %s", err.Error())
+	}
+	if len(track) == 0 {
+		return nil, fmt.Errorf("no soundcloud track found at \"%s\"", url)
+	}
+	return &core.SearchResult{
+		Id:    fmt.Sprintf("%s%d", sourcePrefix, track[0].ID),
+		Title: track[0].Title,
+		Url:   url,
+	}, nil
+}
+
+// Download fetches the progressive audio stream for result and writes it to
+// a local file, returning a Media pointing at it.
+func (s *Service) Download(result *core.SearchResult) (*core.Media, error) {
+	track, err := s.client.GetTrackInfo(scapi.GetTrackInfoOptions{URL: result.Url})
+	if err != nil || len(track) == 0 {
+		return nil, fmt.Errorf("failed to resolve soundcloud track: %s", err.Error())
+	}
+	transcodings := track[0].Media.Transcodings
+	if len(transcodings) == 0 {
+		return nil, errors.New("soundcloud track has no available transcodings")
+	}
+
+	filePath := fmt.Sprintf("sc-%d.mp3", track[0].ID)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create \"%s\": %s", filePath, err.Error())
+	}
+	defer file.Close()
+	if err := s.client.DownloadTrack(transcodings[0], file); err != nil {
+		return nil, fmt.Errorf("failed to download soundcloud track: %s", err.Error())
+	}
+	return core.NewMedia(result.Title, filePath), nil
+}
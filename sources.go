@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/TwinProduction/discord-music-bot/config"
+	"github.com/TwinProduction/discord-music-bot/core"
+	"github.com/TwinProduction/discord-music-bot/direct"
+	"github.com/TwinProduction/discord-music-bot/player"
+	"github.com/TwinProduction/discord-music-bot/youtube"
+	"github.com/bwmarrin/discordgo"
+)
+
+// youtubeHosts and soundcloudHosts are used by detectSource to recognize a
+// !play query as a link belonging to one of the dedicated sources.
+var (
+	youtubeHosts    = []string{"youtube.com", "youtu.be"}
+	soundcloudHosts = []string{"soundcloud.com"}
+)
+
+// HandleSoundCloudCommand resolves a SoundCloud track link and adds it to
+// the caller's guild queue. Unlike HandleYoutubeCommand it has no playlist
+// support, since SoundCloud playlists aren't exposed by soundcloud.Service.
+func HandleSoundCloudCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	guildPlayer, createNewWorker := getOrCreateGuildPlayer(message.GuildID)
+	guildName := GetGuildNameById(bot, message.GuildID)
+
+	voiceChannelId, err := GetVoiceChannelWhereMessageAuthorIs(bot, message)
+	if err != nil {
+		log.Printf("[%s] Failed to find voice channel where message author is located: %s", guildName, err.Error())
+		_, _ = bot.ChannelMessageSend(message.ChannelID, err.Error())
+		return
+	}
+
+	resolveAndEnqueue(bot, message, guildPlayer, guildName, voiceChannelId, soundcloudService, query, createNewWorker)
+}
+
+// HandlePlayCommand resolves query against whichever source it looks like
+// it belongs to: a YouTube or SoundCloud link goes to that source, a direct
+// link to an mp3/opus/ogg file goes to the direct source, and anything else
+// is treated as a YouTube search.
+func HandlePlayCommand(bot *discordgo.Session, message *discordgo.MessageCreate, query string) {
+	guildPlayer, createNewWorker := getOrCreateGuildPlayer(message.GuildID)
+	guildName := GetGuildNameById(bot, message.GuildID)
+
+	voiceChannelId, err := GetVoiceChannelWhereMessageAuthorIs(bot, message)
+	if err != nil {
+		log.Printf("[%s] Failed to find voice channel where message author is located: %s", guildName, err.Error())
+		_, _ = bot.ChannelMessageSend(message.ChannelID, err.Error())
+		return
+	}
+
+	source := detectSource(query)
+	if source == youtubeService {
+		if playlistId, ok := youtube.ExtractPlaylistId(query); ok {
+			log.Printf("[%s] Detected playlist %s, resolving incrementally", guildName, playlistId)
+			HandlePlaylistEnqueue(bot, message, guildPlayer, guildName, voiceChannelId, playlistId, createNewWorker)
+			return
+		}
+	}
+
+	resolveAndEnqueue(bot, message, guildPlayer, guildName, voiceChannelId, source, query, createNewWorker)
+}
+
+// detectSource picks the Source that should handle query, based on the host
+// of the link it contains. Plain-text queries and anything that doesn't
+// match a known host fall back to youtubeService.
+func detectSource(query string) core.Source {
+	lower := strings.ToLower(query)
+	if direct.IsDirectLink(query) {
+		return directService
+	}
+	for _, host := range soundcloudHosts {
+		if strings.Contains(lower, host) {
+			return soundcloudService
+		}
+	}
+	for _, host := range youtubeHosts {
+		if strings.Contains(lower, host) {
+			return youtubeService
+		}
+	}
+	return youtubeService
+}
+
+// resolveAndEnqueue searches source for query, resolves and caches the
+// result, adds it to guildPlayer's queue, and starts a worker for the
+// guild if createNewWorker is set. It's the shared tail end of
+// HandleYoutubeCommand, HandleSoundCloudCommand and HandlePlayCommand.
+func resolveAndEnqueue(bot *discordgo.Session, message *discordgo.MessageCreate, guildPlayer *player.GuildPlayer, guildName, voiceChannelId string, source core.Source, query string, createNewWorker bool) {
+	maxQueueSize := config.Get().GuildConfig(message.GuildID).GetMaxQueueSize()
+	if guildPlayer.Len() >= maxQueueSize {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, "The queue is full!")
+		return
+	}
+
+	log.Printf("[%s] Searching for \"%s\"", guildName, query)
+	result, err := source.Search(query)
+	if err != nil {
+		log.Printf("[%s] Failed to resolve \"%s\": %s", guildName, query, err.Error())
+		_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to resolve \"%s\": %s", query, err.Error()))
+		return
+	}
+	log.Printf("[%s] Resolved \"%s\" from query \"%s\"", guildName, result.Title, query)
+
+	handle, err := resolveMedia(source, result, guildName)
+	if err != nil {
+		log.Printf("[%s] Failed to resolve media for \"%s\": %s", guildName, result.Title, err.Error())
+		_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to download \"%s\": %s", result.Title, err.Error()))
+		return
+	}
+	media := handle.Media()
+
+	position := guildPlayer.EnqueueWithRelease(media, handle.Release)
+	log.Printf("[%s] Added media with title \"%s\" to queue at position %d", guildName, media.Title, position)
+	_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":musical_note: Added media with title \"%s\" to queue at position %d", media.Title, position))
+
+	if createNewWorker {
+		log.Printf("[%s] Starting worker", guildName)
+		go func() {
+			if err := worker(bot, message.GuildID, voiceChannelId); err != nil {
+				log.Printf("[%s] Failed to start worker: %s", guildName, err.Error())
+				_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf("Unable to start voice worker: %s", err.Error()))
+			}
+		}()
+	}
+}
@@ -0,0 +1,38 @@
+package main
+
+import "testing"
+
+func TestDetectSource_RecognizesYoutubeLinks(t *testing.T) {
+	for _, query := range []string{
+		"https://www.youtube.com/watch?v=abc123",
+		"https://youtu.be/abc123",
+	} {
+		if detectSource(query) != youtubeService {
+			t.Errorf("expected %q to resolve to the youtube source", query)
+		}
+	}
+}
+
+func TestDetectSource_RecognizesSoundCloudLinks(t *testing.T) {
+	if detectSource("https://soundcloud.com/artist/track") != soundcloudService {
+		t.Error("expected a soundcloud.com link to resolve to the soundcloud source")
+	}
+}
+
+func TestDetectSource_RecognizesDirectLinks(t *testing.T) {
+	if detectSource("https://example.com/song.mp3") != directService {
+		t.Error("expected a direct mp3 link to resolve to the direct source")
+	}
+}
+
+func TestDetectSource_FallsBackToYoutubeForPlainQueries(t *testing.T) {
+	if detectSource("never gonna give you up") != youtubeService {
+		t.Error("expected a plain-text query to fall back to the youtube source")
+	}
+}
+
+func TestDetectSource_PrefersDirectLinkOverHostMatching(t *testing.T) {
+	if detectSource("https://soundcloud.com/artist/track.mp3") != directService {
+		t.Error("expected a direct-link extension to take priority over host matching")
+	}
+}
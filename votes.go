@@ -0,0 +1,64 @@
+package main
+
+import "sync"
+
+// VoteHolder tracks which users have voted to skip the currently playing
+// track in a guild.
+type VoteHolder struct {
+	mu     sync.Mutex
+	voters map[string]bool
+}
+
+// NewVoteHolder creates an empty VoteHolder.
+func NewVoteHolder() *VoteHolder {
+	return &VoteHolder{voters: make(map[string]bool)}
+}
+
+// Vote records userID's vote to skip and returns the number of votes cast
+// so far.
+func (v *VoteHolder) Vote(userID string) int {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.voters[userID] = true
+	return len(v.voters)
+}
+
+// Remove removes userID's vote, e.g. because they left the voice channel.
+func (v *VoteHolder) Remove(userID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.voters, userID)
+}
+
+// Reset clears all votes. Called whenever a new track starts playing.
+func (v *VoteHolder) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.voters = make(map[string]bool)
+}
+
+var (
+	voteHolders      = make(map[string]*VoteHolder)
+	voteHoldersMutex = sync.RWMutex{}
+)
+
+// getOrCreateVoteHolder returns the VoteHolder for guildID, creating one if
+// it doesn't exist yet.
+func getOrCreateVoteHolder(guildID string) *VoteHolder {
+	voteHoldersMutex.Lock()
+	defer voteHoldersMutex.Unlock()
+	voteHolder, ok := voteHolders[guildID]
+	if !ok {
+		voteHolder = NewVoteHolder()
+		voteHolders[guildID] = voteHolder
+	}
+	return voteHolder
+}
+
+// findVoteHolder returns the VoteHolder for guildID without creating one.
+func findVoteHolder(guildID string) (*VoteHolder, bool) {
+	voteHoldersMutex.RLock()
+	defer voteHoldersMutex.RUnlock()
+	voteHolder, ok := voteHolders[guildID]
+	return voteHolder, ok
+}
@@ -0,0 +1,54 @@
+package main
+
+import "testing"
+
+func TestVoteHolder_VoteCountsUniqueVoters(t *testing.T) {
+	v := NewVoteHolder()
+	if votes := v.Vote("user-1"); votes != 1 {
+		t.Errorf("expected 1 vote, got %d", votes)
+	}
+	if votes := v.Vote("user-2"); votes != 2 {
+		t.Errorf("expected 2 votes, got %d", votes)
+	}
+	if votes := v.Vote("user-1"); votes != 2 {
+		t.Errorf("expected a repeat vote from the same user not to count twice, got %d", votes)
+	}
+}
+
+func TestVoteHolder_RemoveDropsAVote(t *testing.T) {
+	v := NewVoteHolder()
+	v.Vote("user-1")
+	v.Vote("user-2")
+
+	v.Remove("user-1")
+
+	if votes := v.Vote("user-2"); votes != 1 {
+		t.Errorf("expected 1 vote remaining after removal, got %d", votes)
+	}
+}
+
+func TestVoteHolder_ResetClearsAllVotes(t *testing.T) {
+	v := NewVoteHolder()
+	v.Vote("user-1")
+	v.Vote("user-2")
+
+	v.Reset()
+
+	if votes := v.Vote("user-3"); votes != 1 {
+		t.Errorf("expected the vote count to restart from 1 after Reset, got %d", votes)
+	}
+}
+
+func TestGetOrCreateVoteHolder_ReturnsSameInstanceForSameGuild(t *testing.T) {
+	first := getOrCreateVoteHolder("guild-votes-1")
+	second := getOrCreateVoteHolder("guild-votes-1")
+	if first != second {
+		t.Error("expected repeated lookups of the same guild to return the same VoteHolder")
+	}
+}
+
+func TestFindVoteHolder_ReportsMissingGuild(t *testing.T) {
+	if _, ok := findVoteHolder("guild-votes-does-not-exist"); ok {
+		t.Error("expected no VoteHolder for a guild that never voted")
+	}
+}
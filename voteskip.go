@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/TwinProduction/discord-music-bot/config"
+	"github.com/TwinProduction/discord-music-bot/player"
+	"github.com/bwmarrin/discordgo"
+)
+
+// HandleVoteSkipCommand lets members vote to skip the current track. DJs
+// bypass the vote entirely and skip immediately, same as !skip. When the
+// guild has no DJ role configured, every member goes through the vote.
+func HandleVoteSkipCommand(bot *discordgo.Session, message *discordgo.MessageCreate, _ string) {
+	guildPlayer, ok := findGuildPlayer(message.GuildID)
+	if !ok {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	if config.Get().GuildConfig(message.GuildID).GetDJRoleId() != "" && userMayManagePlayback(bot, message) {
+		if !guildPlayer.Send(player.ControlMessage{Command: player.CommandSkip}) {
+			_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+			return
+		}
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ":track_next: Skipped by a DJ")
+		return
+	}
+
+	botVoiceChannelId := guildPlayer.VoiceChannelId()
+	if botVoiceChannelId == "" {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrNothingPlaying.Error())
+		return
+	}
+	voterVoiceChannelId, err := GetVoiceChannelWhereMessageAuthorIs(bot, message)
+	if err != nil {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, err.Error())
+		return
+	}
+	if voterVoiceChannelId != botVoiceChannelId {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrVoterNotInBotChannel.Error())
+		return
+	}
+	required, err := requiredVoteSkipCount(bot, message.GuildID, botVoiceChannelId)
+	if err != nil {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, err.Error())
+		return
+	}
+
+	voteHolder := getOrCreateVoteHolder(message.GuildID)
+	votes := voteHolder.Vote(message.Author.ID)
+	if votes < required {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, fmt.Sprintf(":ballot_box: %d/%d votes to skip", votes, required))
+		return
+	}
+
+	voteHolder.Reset()
+	if !guildPlayer.Send(player.ControlMessage{Command: player.CommandSkip}) {
+		_, _ = bot.ChannelMessageSend(message.ChannelID, ErrPlayerBusy.Error())
+		return
+	}
+	_, _ = bot.ChannelMessageSend(message.ChannelID, ":track_next: Vote passed, skipping")
+}
+
+// requiredVoteSkipCount returns how many votes are needed to skip, based on
+// the guild's configured vote-skip threshold and the number of non-bot
+// members currently in voiceChannelId, which must be the voice channel the
+// bot itself joined to play.
+func requiredVoteSkipCount(bot *discordgo.Session, guildID, voiceChannelId string) (int, error) {
+	guild, err := bot.Guild(guildID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up guild: %s", err.Error())
+	}
+	nonBotMembers := 0
+	for _, voiceState := range guild.VoiceStates {
+		if voiceState.ChannelID != voiceChannelId {
+			continue
+		}
+		member, err := bot.GuildMember(guildID, voiceState.UserID)
+		if err != nil || member.User.Bot {
+			continue
+		}
+		nonBotMembers++
+	}
+	threshold := config.Get().GuildConfig(guildID).GetVoteSkipThreshold()
+	required := int(math.Ceil(float64(nonBotMembers) * threshold))
+	if required < 1 {
+		required = 1
+	}
+	return required, nil
+}
+
+// HandleVoiceStateUpdate clears a user's skip vote when they leave the
+// voice channel the bot is playing in.
+func HandleVoiceStateUpdate(bot *discordgo.Session, update *discordgo.VoiceStateUpdate) {
+	if update.BeforeUpdate == nil || update.ChannelID == update.BeforeUpdate.ChannelID {
+		return
+	}
+	voteHolder, ok := findVoteHolder(update.GuildID)
+	if !ok {
+		return
+	}
+	voteHolder.Remove(update.UserID)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+	"github.com/TwinProduction/discord-music-bot/ffmpeg"
+	"github.com/TwinProduction/discord-music-bot/player"
+	"github.com/bwmarrin/discordgo"
+)
+
+// errStopped is returned up the call stack by playTrack/waitForResume when a
+// CommandStop was received, so worker knows to tear down instead of moving
+// on to the next track.
+var errStopped = errors.New("playback stopped")
+
+// errSkip is returned by waitForResume when a CommandSkip arrives while
+// playback is paused.
+var errSkip = errors.New("playback skipped")
+
+// worker owns playback for a single guild: it joins the voice channel, then
+// repeatedly pulls the next track off the guild's GuildPlayer and streams
+// its audio to Discord, all while watching the player's control channel so
+// !pause, !resume, !skip, !stop and !seek can interrupt it mid-song.
+func worker(bot *discordgo.Session, guildID, voiceChannelId string) error {
+	guildName := GetGuildNameById(bot, guildID)
+	vc, err := bot.ChannelVoiceJoin(guildID, voiceChannelId, false, true)
+	if err != nil {
+		return fmt.Errorf("failed to join voice channel: %s", err.Error())
+	}
+	guildPlayer, ok := findGuildPlayer(guildID)
+	if !ok {
+		return fmt.Errorf("no GuildPlayer registered for guild %s", guildID)
+	}
+	defer func() {
+		_ = vc.Disconnect()
+		guildPlayer.MarkStopped()
+		guildPlayersMutex.Lock()
+		delete(guildPlayers, guildID)
+		guildPlayersMutex.Unlock()
+	}()
+	guildPlayer.SetVoiceChannelId(voiceChannelId)
+	for {
+		media := guildPlayer.Next()
+		if media == nil {
+			if guildPlayer.HasPendingProducers() {
+				time.Sleep(500 * time.Millisecond)
+				continue
+			}
+			log.Printf("[%s] Queue is empty, stopping worker", guildName)
+			return nil
+		}
+		log.Printf("[%s] Now playing \"%s\"", guildName, media.Title)
+		if voteHolder, ok := findVoteHolder(guildID); ok {
+			voteHolder.Reset()
+		}
+		if err := playTrack(guildPlayer, vc, media, 0); err != nil {
+			if errors.Is(err, errStopped) {
+				log.Printf("[%s] Playback stopped", guildName)
+				guildPlayer.ReleaseCurrent()
+				return nil
+			}
+			log.Printf("[%s] Failed to play \"%s\": %s", guildName, media.Title, err.Error())
+		}
+	}
+}
+
+// playTrack streams media's audio frames to vc starting at startAt, until
+// the track finishes naturally or a control message says otherwise.
+func playTrack(guildPlayer *player.GuildPlayer, vc *discordgo.VoiceConnection, media *core.Media, startAt time.Duration) error {
+	stream, err := ffmpeg.NewStream(media, startAt)
+	if err != nil {
+		return fmt.Errorf("failed to open audio stream: %s", err.Error())
+	}
+	defer stream.Close()
+
+	_ = vc.Speaking(true)
+	defer func() { _ = vc.Speaking(false) }()
+
+	for {
+		select {
+		case message := <-guildPlayer.Control:
+			switch message.Command {
+			case player.CommandPause:
+				guildPlayer.SetPaused(true)
+				resumeErr := waitForResume(guildPlayer)
+				guildPlayer.SetPaused(false)
+				switch resumeErr {
+				case errStopped:
+					return errStopped
+				case errSkip:
+					return nil
+				}
+			case player.CommandSkip:
+				return nil
+			case player.CommandStop:
+				return errStopped
+			case player.CommandSeek:
+				return playTrack(guildPlayer, vc, media, message.Seek)
+			case player.CommandSetLoop:
+				guildPlayer.SetLoop(message.Loop)
+			}
+		default:
+			frame, err := stream.ReadFrame()
+			if err != nil {
+				// Track finished naturally
+				return nil
+			}
+			vc.OpusSend <- frame
+			guildPlayer.SetElapsed(stream.Position())
+		}
+	}
+}
+
+// waitForResume blocks until playback is resumed, skipped or stopped while
+// paused.
+func waitForResume(guildPlayer *player.GuildPlayer) error {
+	for message := range guildPlayer.Control {
+		switch message.Command {
+		case player.CommandResume:
+			return nil
+		case player.CommandSkip:
+			return errSkip
+		case player.CommandStop:
+			return errStopped
+		}
+	}
+	return errStopped
+}
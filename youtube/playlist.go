@@ -0,0 +1,76 @@
+package youtube
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+)
+
+// ExtractPlaylistId returns the playlist ID encoded in a YouTube URL's
+// "list" query parameter, if any. This covers both dedicated playlist URLs
+// and video URLs that also carry a ?list= parameter.
+func ExtractPlaylistId(rawUrl string) (string, bool) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil {
+		return "", false
+	}
+	listId := parsed.Query().Get("list")
+	return listId, listId != ""
+}
+
+// PlaylistSize returns the total number of items in playlistId.
+func (s *Service) PlaylistSize(playlistId string) (int, error) {
+	client, err := s.client()
+	if err != nil {
+		return 0, err
+	}
+	response, err := client.PlaylistItems.List([]string{"id"}).PlaylistId(playlistId).MaxResults(1).Do()
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch playlist size: %s", err.Error())
+	}
+	return int(response.PageInfo.TotalResults), nil
+}
+
+// StreamPlaylist pages through every item of playlistId and pushes each one
+// onto the returned channel as soon as its page is fetched, so the caller
+// doesn't have to wait for the whole playlist to resolve before queuing
+// tracks. The channel is closed once every page has been read; if a page
+// fails to fetch, the error is sent on errs before results is closed. errs
+// is closed right after results.
+func (s *Service) StreamPlaylist(playlistId string) (<-chan *core.SearchResult, <-chan error) {
+	results := make(chan *core.SearchResult)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(results)
+		client, err := s.client()
+		if err != nil {
+			errs <- err
+			return
+		}
+		pageToken := ""
+		for {
+			call := client.PlaylistItems.List([]string{"snippet"}).PlaylistId(playlistId).MaxResults(50)
+			if pageToken != "" {
+				call = call.PageToken(pageToken)
+			}
+			response, err := call.Do()
+			if err != nil {
+				errs <- fmt.Errorf("failed to fetch playlist page: %s", err.Error())
+				return
+			}
+			for _, item := range response.Items {
+				results <- &core.SearchResult{
+					Id:    videoSourceId(item.Snippet.ResourceId.VideoId),
+					Title: item.Snippet.Title,
+				}
+			}
+			if response.NextPageToken == "" {
+				return
+			}
+			pageToken = response.NextPageToken
+		}
+	}()
+	return results, errs
+}
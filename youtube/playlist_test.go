@@ -0,0 +1,35 @@
+package youtube
+
+import "testing"
+
+func TestExtractPlaylistId_FromDedicatedPlaylistUrl(t *testing.T) {
+	id, ok := ExtractPlaylistId("https://www.youtube.com/playlist?list=PL12345")
+	if !ok {
+		t.Fatal("expected a playlist id to be found")
+	}
+	if id != "PL12345" {
+		t.Errorf("expected \"PL12345\", got %q", id)
+	}
+}
+
+func TestExtractPlaylistId_FromVideoUrlWithListParam(t *testing.T) {
+	id, ok := ExtractPlaylistId("https://www.youtube.com/watch?v=abc123&list=PL12345")
+	if !ok {
+		t.Fatal("expected a playlist id to be found")
+	}
+	if id != "PL12345" {
+		t.Errorf("expected \"PL12345\", got %q", id)
+	}
+}
+
+func TestExtractPlaylistId_ReturnsFalseWithoutListParam(t *testing.T) {
+	if _, ok := ExtractPlaylistId("https://www.youtube.com/watch?v=abc123"); ok {
+		t.Error("expected no playlist id to be found")
+	}
+}
+
+func TestExtractPlaylistId_ReturnsFalseForInvalidUrl(t *testing.T) {
+	if _, ok := ExtractPlaylistId("://not a url"); ok {
+		t.Error("expected an invalid url to report no playlist id")
+	}
+}
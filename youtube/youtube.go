@@ -0,0 +1,80 @@
+package youtube
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/TwinProduction/discord-music-bot/core"
+	"github.com/TwinProduction/discord-music-bot/ffmpeg"
+	"google.golang.org/api/option"
+	youtubeapi "google.golang.org/api/youtube/v3"
+)
+
+// sourcePrefix namespaces the video IDs this package hands out as
+// core.SearchResult.Id, so they can't collide with IDs from other sources
+// sharing the same mediacache.
+const sourcePrefix = "yt:"
+
+// videoSourceId builds the SearchResult.Id for a video ID.
+func videoSourceId(videoId string) string {
+	return sourcePrefix + videoId
+}
+
+// Service wraps the YouTube Data API, used to resolve queries and playlists
+// to videos, and yt-dlp, used to download their audio.
+type Service struct {
+	apiKey string
+}
+
+// NewService creates a Service that authenticates against the YouTube Data
+// API with apiKey.
+func NewService(apiKey string) *Service {
+	return &Service{apiKey: apiKey}
+}
+
+// client lazily creates a YouTube Data API client.
+func (s *Service) client() (*youtubeapi.Service, error) {
+	client, err := youtubeapi.NewService(context.Background(), option.WithAPIKey(s.apiKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create youtube client: %s", err.Error())
+	}
+	return client, nil
+}
+
+// Search returns the single best match for query.
+func (s *Service) Search(query string) (*core.SearchResult, error) {
+	client, err := s.client()
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Search.List([]string{"id", "snippet"}).Q(query).MaxResults(1).Type("video").Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to search youtube: %s", err.Error())
+	}
+	if len(response.Items) == 0 {
+		return nil, fmt.Errorf("no results found for query \"%s\"", query)
+	}
+	item := response.Items[0]
+	return &core.SearchResult{Id: videoSourceId(item.Id.VideoId), Title: item.Snippet.Title}, nil
+}
+
+// Download fetches the video for result using yt-dlp, extracts its audio
+// with ffmpeg, and returns a Media pointing at the resulting mp3.
+func (s *Service) Download(result *core.SearchResult) (*core.Media, error) {
+	videoId := strings.TrimPrefix(result.Id, sourcePrefix)
+	filePath := fmt.Sprintf("%s.mp4", videoId)
+	cmd := exec.Command("yt-dlp", "-f", "bestaudio", "-o", filePath, fmt.Sprintf("https://www.youtube.com/watch?v=%s", videoId))
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("failed to download video: %s: %s", err.Error(), string(output))
+	}
+	media := core.NewMedia(result.Title, filePath)
+	if err := ffmpeg.ConvertVideoToAudio(media); err != nil {
+		_ = os.Remove(media.FilePath)
+		return nil, fmt.Errorf("failed to convert video to audio: %s", err.Error())
+	}
+	return media, nil
+}